@@ -1,9 +1,13 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/codecrafters-io/git-starter-go/internal/index"
 	"github.com/codecrafters-io/git-starter-go/internal/plumbing"
 )
 
@@ -16,6 +20,22 @@ func main() {
 
 	switch command := os.Args[1]; command {
 	case "init":
+		format := plumbing.SHA1
+		for _, arg := range os.Args[2:] {
+			if !strings.HasPrefix(arg, "--object-format=") {
+				continue
+			}
+			switch v := strings.TrimPrefix(arg, "--object-format="); v {
+			case "sha1":
+				format = plumbing.SHA1
+			case "sha256":
+				format = plumbing.SHA256
+			default:
+				fmt.Fprintf(os.Stderr, "Unknown object format: %s\n", v)
+				os.Exit(1)
+			}
+		}
+
 		for _, dir := range []string{".git", ".git/objects", ".git/refs"} {
 			if err := os.MkdirAll(dir, 0755); err != nil {
 				fmt.Fprintf(os.Stderr, "Error creating directory: %s\n", err)
@@ -27,13 +47,17 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error writing file: %s\n", err)
 		}
 
+		if err := plumbing.WriteRepositoryConfig(".git", format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing config: %s\n", err)
+		}
+
 		fmt.Println("Initialized git directory")
 
 	case "cat-file":
-		checkGitInitialized()
+		repo := openRepository()
 
 		if len(os.Args) != 4 {
-			fmt.Fprintln(os.Stderr, "Usage: cat-file -{t, s, p} sha1HexString")
+			fmt.Fprintln(os.Stderr, "Usage: cat-file -{t, s, p} hexString")
 			os.Exit(1)
 		}
 
@@ -44,12 +68,12 @@ func main() {
 			os.Exit(1)
 		}
 
-		if len(hash) != 40 {
+		if !isValidHashLen(hash) {
 			fmt.Fprintf(os.Stderr, "Invalid hash: %s\n", hash)
 			os.Exit(1)
 		}
 
-		meta, err := plumbing.GetGitObjectMetadata(hash)
+		meta, err := plumbing.GetGitObjectMetadata(hash, repo.Options()...)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error decoding object file: %s\n", err)
 			os.Exit(1)
@@ -63,7 +87,7 @@ func main() {
 			fmt.Println(meta.Size)
 
 		case "-p":
-			obj, err := plumbing.NewGitObjectFromHash(hash)
+			obj, err := plumbing.NewGitObjectFromHash(hash, repo.Options()...)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error decoding object file: %s\n", err)
 				os.Exit(1)
@@ -77,7 +101,7 @@ func main() {
 		}
 
 	case "hash-object":
-		checkGitInitialized()
+		repo := openRepository()
 
 		if len(os.Args) > 3 && os.Args[2] != "-w" {
 			fmt.Fprintf(os.Stderr, "Invalid flag: %s\n", os.Args[2])
@@ -92,7 +116,7 @@ func main() {
 			fn = os.Args[2]
 		}
 
-		blob, err := plumbing.NewBlobObjectFromFilePath(fn)
+		blob, err := plumbing.NewBlobObjectFromFilePath(fn, repo.Options()...)
 		if err != nil {
 			fmt.Fprintf(
 				os.Stderr,
@@ -115,10 +139,10 @@ func main() {
 			}
 		}
 
-		fmt.Printf("%x\n", blob.Sha)
+		fmt.Println(blob.Sha.String())
 
 	case "ls-tree":
-		checkGitInitialized()
+		repo := openRepository()
 
 		var hash string
 		var nameOnly bool
@@ -128,22 +152,22 @@ func main() {
 		} else if len(os.Args) == 3 {
 			hash = os.Args[2]
 		} else {
-			fmt.Fprintln(os.Stderr, "Usage: ls-tree [--name-only] sha1HexString")
+			fmt.Fprintln(os.Stderr, "Usage: ls-tree [--name-only] hexString")
 			os.Exit(1)
 		}
 
-		if len(hash) != 40 {
+		if !isValidHashLen(hash) {
 			fmt.Fprintf(os.Stderr, "Invalid hash: %s\n", hash)
 			os.Exit(1)
 		}
 
-		meta, err := plumbing.GetGitObjectMetadata(hash)
+		meta, err := plumbing.GetGitObjectMetadata(hash, repo.Options()...)
 		if err != nil || meta.Header != plumbing.Tree {
 			fmt.Fprintf(os.Stderr, "Invalid tree object: %s\n", err)
 			os.Exit(1)
 		}
 
-		ls, err := plumbing.LsTree(hash, nameOnly)
+		ls, err := plumbing.LsTree(hash, nameOnly, repo.Options()...)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error creating tree object: %s\n", err)
 			os.Exit(1)
@@ -152,21 +176,241 @@ func main() {
 		fmt.Print(ls)
 
 	case "write-tree":
-		checkGitInitialized()
-		dir, err := os.Getwd()
+		requireSHA1Repository("write-tree")
+
+		idx, err := index.Open(".git/index")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting cwd: %s\n", err)
+			fmt.Fprintf(os.Stderr, "Error reading index: %s\n", err)
 			os.Exit(1)
 		}
 
-		tree, err := plumbing.NewTreeObjectFromFilePath(dir)
+		tree, err := index.BuildTreeFromIndex(idx)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error creating tree object: %s\n", err)
 			os.Exit(1)
 		}
 
-		tree.WriteToFile()
-		fmt.Printf("%x\n", tree.Sha)
+		if err := tree.WriteToFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing tree object: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(tree.Sha.String())
+
+	case "add":
+		requireSHA1Repository("add")
+
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: add <path>...")
+			os.Exit(1)
+		}
+
+		idx, err := index.Open(".git/index")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading index: %s\n", err)
+			os.Exit(1)
+		}
+
+		for _, path := range os.Args[2:] {
+			if err := idx.Add(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Error adding %s: %s\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		if err := idx.Write(".git/index"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing index: %s\n", err)
+			os.Exit(1)
+		}
+
+	case "rm":
+		checkGitInitialized()
+
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: rm <path>...")
+			os.Exit(1)
+		}
+
+		idx, err := index.Open(".git/index")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading index: %s\n", err)
+			os.Exit(1)
+		}
+
+		for _, path := range os.Args[2:] {
+			if err := idx.Remove(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Error removing %s: %s\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		if err := idx.Write(".git/index"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing index: %s\n", err)
+			os.Exit(1)
+		}
+
+	case "status":
+		checkGitInitialized()
+
+		idx, err := index.Open(".git/index")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading index: %s\n", err)
+			os.Exit(1)
+		}
+
+		report, err := idx.Status(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error computing status: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Print(report)
+
+	case "commit-tree":
+		repo := openRepository()
+
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: commit-tree treeHexString [-p parentHexString]... [-m message]")
+			os.Exit(1)
+		}
+
+		treeHash := os.Args[2]
+		if !isValidHashLen(treeHash) {
+			fmt.Fprintf(os.Stderr, "Invalid hash: %s\n", treeHash)
+			os.Exit(1)
+		}
+
+		var parentHashes []string
+		var message string
+		for i := 3; i < len(os.Args); i++ {
+			switch os.Args[i] {
+			case "-p":
+				i++
+				if i >= len(os.Args) {
+					fmt.Fprintln(os.Stderr, "Usage: commit-tree treeHexString [-p parentHexString]... [-m message]")
+					os.Exit(1)
+				}
+				parentHashes = append(parentHashes, os.Args[i])
+
+			case "-m":
+				i++
+				if i >= len(os.Args) {
+					fmt.Fprintln(os.Stderr, "Usage: commit-tree treeHexString [-p parentHexString]... [-m message]")
+					os.Exit(1)
+				}
+				message = os.Args[i]
+
+			default:
+				fmt.Fprintf(os.Stderr, "Unknown flag: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+		}
+
+		tree, err := hexStringToBytes(treeHash)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid hash: %s\n", treeHash)
+			os.Exit(1)
+		}
+
+		var parents [][]byte
+		for _, parentHash := range parentHashes {
+			parent, err := hexStringToBytes(parentHash)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid hash: %s\n", parentHash)
+				os.Exit(1)
+			}
+			parents = append(parents, parent)
+		}
+
+		commit, err := plumbing.NewCommitObject(tree, parents, authorLine(), committerLine(), message, repo.Options()...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating commit object: %s\n", err)
+			os.Exit(1)
+		}
+
+		if err := commit.WriteToFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing commit object to file: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(commit.Sha.String())
+
+	case "archive":
+		repo := openRepository()
+
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: archive [--format=tar|tar.gz|zip] [--prefix=<prefix>] treeHexString")
+			os.Exit(1)
+		}
+
+		format := plumbing.Tar
+		prefix := ""
+		var hash string
+
+		for _, arg := range os.Args[2:] {
+			switch {
+			case strings.HasPrefix(arg, "--format="):
+				switch v := strings.TrimPrefix(arg, "--format="); v {
+				case "tar":
+					format = plumbing.Tar
+				case "tar.gz", "tgz":
+					format = plumbing.TarGz
+				case "zip":
+					format = plumbing.Zip
+				default:
+					fmt.Fprintf(os.Stderr, "Unknown archive format: %s\n", v)
+					os.Exit(1)
+				}
+
+			case strings.HasPrefix(arg, "--prefix="):
+				prefix = strings.TrimPrefix(arg, "--prefix=")
+
+			default:
+				hash = arg
+			}
+		}
+
+		if !isValidHashLen(hash) {
+			fmt.Fprintf(os.Stderr, "Invalid hash: %s\n", hash)
+			os.Exit(1)
+		}
+
+		if err := plumbing.WriteArchive(os.Stdout, hash, format, prefix, repo.Options()...); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing archive: %s\n", err)
+			os.Exit(1)
+		}
+
+	case "log":
+		repo := openRepository()
+
+		var hash string
+		if len(os.Args) > 2 {
+			hash = os.Args[2]
+		} else {
+			var err error
+			hash, err = resolveHead()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error resolving HEAD: %s\n", err)
+				os.Exit(1)
+			}
+		}
+
+		for hash != "" {
+			commit, err := plumbing.NewCommitObjectFromHash(hash, repo.Options()...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error decoding object file: %s\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("commit %s\n", hash)
+			fmt.Print(commit)
+			fmt.Println()
+
+			if len(commit.Parents) == 0 {
+				break
+			}
+			hash = commit.Parents[0].String()
+		}
 
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command %s\n", command)
@@ -187,3 +431,97 @@ func checkGitInitialized() {
 		os.Exit(1)
 	}
 }
+
+// openRepository checks that the repository is initialized and opens it,
+// reading its hash algorithm so every plumbing call below operates in that
+// one algorithm consistently.
+func openRepository() *plumbing.Repository {
+	checkGitInitialized()
+
+	repo, err := plumbing.OpenRepository(".git")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening repository: %s\n", err)
+		os.Exit(1)
+	}
+	return repo
+}
+
+// requireSHA1Repository opens the repository and exits with an error if it
+// isn't SHA1. The staging index format (".git/index" v2) hardcodes a 20-byte
+// sha per entry, so commands built on it — "add" and "write-tree" — can't
+// yet represent a sha256 repository's 32-byte object ids.
+func requireSHA1Repository(command string) *plumbing.Repository {
+	repo := openRepository()
+	if repo.Format() != plumbing.SHA1 {
+		fmt.Fprintf(os.Stderr, "%s: the staging index does not support sha256 repositories yet\n", command)
+		os.Exit(1)
+	}
+	return repo
+}
+
+// isValidHashLen reports whether s is the right length for a hex-encoded
+// object hash: 40 characters under sha1, 64 under sha256.
+func isValidHashLen(s string) bool {
+	return len(s) == plumbing.SHA1.HexSize() || len(s) == plumbing.SHA256.HexSize()
+}
+
+// resolveHead reads .git/HEAD and follows its ref to the commit hash it
+// points at.
+func resolveHead() (string, error) {
+	head, err := os.ReadFile(".git/HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	ref := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(head)), "ref:"))
+	ref = strings.TrimSpace(ref)
+
+	hash, err := os.ReadFile(".git/" + ref)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(hash)), nil
+}
+
+// authorLine builds the "name <email> timestamp timezone" line committed to
+// a commit object's author field, reading GIT_AUTHOR_* environment variables
+// the same way real git does and falling back to sane defaults.
+func authorLine() string {
+	return personLine("GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_AUTHOR_DATE")
+}
+
+// committerLine is the committer equivalent of authorLine.
+func committerLine() string {
+	return personLine("GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL", "GIT_COMMITTER_DATE")
+}
+
+func personLine(nameVar, emailVar, dateVar string) string {
+	name := os.Getenv(nameVar)
+	if name == "" {
+		name = "mygit"
+	}
+
+	email := os.Getenv(emailVar)
+	if email == "" {
+		email = "mygit@example.com"
+	}
+
+	date := os.Getenv(dateVar)
+	if date == "" {
+		now := time.Now()
+		date = fmt.Sprintf("%d %s", now.Unix(), now.Format("-0700"))
+	}
+
+	return fmt.Sprintf("%s <%s> %s", name, email, date)
+}
+
+// hexStringToBytes decodes a hex-encoded object hash into its raw bytes,
+// regardless of whether it's 40 (sha1) or 64 (sha256) characters long.
+func hexStringToBytes(s string) ([]byte, error) {
+	b := make([]byte, hex.DecodedLen(len(s)))
+	if _, err := hex.Decode(b, []byte(s)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,522 @@
+// Package index implements the git staging area: reading and writing the
+// binary ".git/index" v2 file, staging/unstaging paths, and assembling a
+// TreeObject hierarchy out of whatever is currently staged.
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/codecrafters-io/git-starter-go/internal/plumbing"
+)
+
+var ErrInvalidIndex = errors.New("Invalid index file: does not start with the DIRC signature.")
+
+var indexSignature = [4]byte{'D', 'I', 'R', 'C'}
+
+const indexVersion = 2
+
+// Entry is one staged file: everything the index keeps about it besides its
+// position in the sorted entry table.
+type Entry struct {
+	Ctime time.Time
+	Mtime time.Time
+	Dev   uint32
+	Ino   uint32
+	Mode  uint32
+	Uid   uint32
+	Gid   uint32
+	Size  uint32
+	Sha   []byte
+	Path  string
+}
+
+// Index is the in-memory form of ".git/index": a flat, path-sorted table of
+// staged files.
+type Index struct {
+	Version uint32
+	Entries []Entry
+}
+
+// Open reads the index file at path. A missing file is not an error — it
+// means nothing has been staged yet, so an empty Index is returned.
+func Open(path string) (*Index, error) {
+	idx := &Index{Version: indexVersion}
+
+	if err := idx.Read(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return idx, nil
+		}
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// Read parses the index file at path, replacing idx's Version and Entries.
+func (idx *Index) Read(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+
+	var sig [4]byte
+	if _, err := io.ReadFull(br, sig[:]); err != nil {
+		return err
+	}
+	if sig != indexSignature {
+		return ErrInvalidIndex
+	}
+
+	var version, count uint32
+	if err := binary.Read(br, binary.BigEndian, &version); err != nil {
+		return err
+	}
+	if err := binary.Read(br, binary.BigEndian, &count); err != nil {
+		return err
+	}
+
+	entries := make([]Entry, 0, count)
+	for i := uint32(0); i < count; i++ {
+		entry, err := readEntry(br)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	idx.Version = version
+	idx.Entries = entries
+	return nil
+}
+
+func readEntry(r *bufio.Reader) (Entry, error) {
+	var fixed [62]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return Entry{}, err
+	}
+
+	var e Entry
+	e.Ctime = time.Unix(int64(binary.BigEndian.Uint32(fixed[0:4])), int64(binary.BigEndian.Uint32(fixed[4:8])))
+	e.Mtime = time.Unix(int64(binary.BigEndian.Uint32(fixed[8:12])), int64(binary.BigEndian.Uint32(fixed[12:16])))
+	e.Dev = binary.BigEndian.Uint32(fixed[16:20])
+	e.Ino = binary.BigEndian.Uint32(fixed[20:24])
+	e.Mode = binary.BigEndian.Uint32(fixed[24:28])
+	e.Uid = binary.BigEndian.Uint32(fixed[28:32])
+	e.Gid = binary.BigEndian.Uint32(fixed[32:36])
+	e.Size = binary.BigEndian.Uint32(fixed[36:40])
+
+	sha := make([]byte, 20)
+	copy(sha, fixed[40:60])
+	e.Sha = sha
+
+	name, err := r.ReadBytes(0)
+	if err != nil {
+		return Entry{}, err
+	}
+	e.Path = string(name[:len(name)-1])
+
+	entryLen := len(fixed) + len(name)
+	if padding := (8 - entryLen%8) % 8; padding > 0 {
+		if _, err := io.CopyN(io.Discard, r, int64(padding)); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	return e, nil
+}
+
+// Write serializes idx, sorted by path, to the index file at path, appending
+// the trailing sha1 checksum real git expects.
+func (idx *Index) Write(path string) error {
+	sort.Slice(idx.Entries, func(i, j int) bool { return idx.Entries[i].Path < idx.Entries[j].Path })
+
+	var buf bytes.Buffer
+	buf.Write(indexSignature[:])
+	binary.Write(&buf, binary.BigEndian, uint32(indexVersion))
+	binary.Write(&buf, binary.BigEndian, uint32(len(idx.Entries)))
+
+	for _, e := range idx.Entries {
+		writeEntry(&buf, e)
+	}
+
+	h := sha1.New()
+	h.Write(buf.Bytes())
+	buf.Write(h.Sum(nil))
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func writeEntry(buf *bytes.Buffer, e Entry) {
+	var fixed [62]byte
+	binary.BigEndian.PutUint32(fixed[0:4], uint32(e.Ctime.Unix()))
+	binary.BigEndian.PutUint32(fixed[4:8], uint32(e.Ctime.Nanosecond()))
+	binary.BigEndian.PutUint32(fixed[8:12], uint32(e.Mtime.Unix()))
+	binary.BigEndian.PutUint32(fixed[12:16], uint32(e.Mtime.Nanosecond()))
+	binary.BigEndian.PutUint32(fixed[16:20], e.Dev)
+	binary.BigEndian.PutUint32(fixed[20:24], e.Ino)
+	binary.BigEndian.PutUint32(fixed[24:28], e.Mode)
+	binary.BigEndian.PutUint32(fixed[28:32], e.Uid)
+	binary.BigEndian.PutUint32(fixed[32:36], e.Gid)
+	binary.BigEndian.PutUint32(fixed[36:40], e.Size)
+	copy(fixed[40:60], e.Sha)
+
+	nameLen := len(e.Path)
+	flags := uint16(nameLen)
+	if nameLen > 0xFFF {
+		flags = 0xFFF
+	}
+	binary.BigEndian.PutUint16(fixed[60:62], flags)
+
+	buf.Write(fixed[:])
+	buf.WriteString(e.Path)
+	buf.WriteByte(0)
+
+	entryLen := len(fixed) + nameLen + 1
+	for i := 0; i < (8-entryLen%8)%8; i++ {
+		buf.WriteByte(0)
+	}
+}
+
+// Add stages path: it hashes the file's current content as a blob, writes
+// that blob to the object store, and inserts or updates path's Entry.
+func (idx *Index) Add(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("index: %q is a directory, add its files individually", path)
+	}
+
+	blob, err := plumbing.NewBlobObjectFromFilePath(path)
+	if err != nil {
+		return err
+	}
+	if err := blob.WriteToFile(); err != nil {
+		return err
+	}
+
+	ctime, dev, ino, uid, gid := statInfo(info)
+
+	idx.upsert(Entry{
+		Ctime: ctime,
+		Mtime: info.ModTime(),
+		Dev:   dev,
+		Ino:   ino,
+		Mode:  fileModeToGitMode(info),
+		Uid:   uid,
+		Gid:   gid,
+		Size:  uint32(info.Size()),
+		Sha:   blob.Sha,
+		Path:  filepath.ToSlash(path),
+	})
+
+	return nil
+}
+
+// Remove unstages path. It is an error to remove a path that isn't staged.
+func (idx *Index) Remove(path string) error {
+	path = filepath.ToSlash(path)
+
+	for i, e := range idx.Entries {
+		if e.Path == path {
+			idx.Entries = append(idx.Entries[:i], idx.Entries[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("index: %q is not staged", path)
+}
+
+func (idx *Index) upsert(e Entry) {
+	for i, existing := range idx.Entries {
+		if existing.Path == e.Path {
+			idx.Entries[i] = e
+			return
+		}
+	}
+
+	idx.Entries = append(idx.Entries, e)
+	sort.Slice(idx.Entries, func(i, j int) bool { return idx.Entries[i].Path < idx.Entries[j].Path })
+}
+
+func statInfo(info os.FileInfo) (ctime time.Time, dev, ino, uid, gid uint32) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Ctim.Sec, st.Ctim.Nsec), uint32(st.Dev), uint32(st.Ino), st.Uid, st.Gid
+	}
+	return info.ModTime(), 0, 0, 0, 0
+}
+
+func fileModeToGitMode(info os.FileInfo) uint32 {
+	switch mode := info.Mode(); {
+	case mode&os.ModeSymlink == os.ModeSymlink:
+		return 0120000
+	case mode&0111 != 0:
+		return 0100755
+	default:
+		return 0100644
+	}
+}
+
+func gitModeToFileType(mode uint32) plumbing.FileType {
+	switch mode {
+	case 0120000:
+		return plumbing.Symlink
+	case 0100755:
+		return plumbing.Executable
+	default:
+		return plumbing.Regular
+	}
+}
+
+// treeNode is a scratch tree used to turn the flat, slash-separated index
+// paths back into the nested hierarchy a TreeObject expects.
+type treeNode struct {
+	isBlob   bool
+	mode     uint32
+	sha      []byte
+	children map[string]*treeNode
+}
+
+// BuildTreeFromIndex assembles the TreeObject hierarchy the staging area
+// describes, the same way `git write-tree` builds a tree from the index
+// instead of scanning the working directory.
+func BuildTreeFromIndex(idx *Index) (*plumbing.TreeObject, error) {
+	root := &treeNode{children: make(map[string]*treeNode)}
+
+	for _, e := range idx.Entries {
+		parts := strings.Split(e.Path, "/")
+		cur := root
+
+		for i, part := range parts {
+			if i == len(parts)-1 {
+				cur.children[part] = &treeNode{isBlob: true, mode: e.Mode, sha: e.Sha}
+				continue
+			}
+
+			child, ok := cur.children[part]
+			if !ok || child.isBlob {
+				child = &treeNode{children: make(map[string]*treeNode)}
+				cur.children[part] = child
+			}
+			cur = child
+		}
+	}
+
+	return buildTree(root), nil
+}
+
+// treeEntryLess orders two sibling tree entries the way git compares them
+// when writing a tree: byte-wise, but a subtree name is compared as if it
+// had a trailing "/", so e.g. "lib.txt" sorts before the "lib" subtree.
+func treeEntryLess(aName string, aIsBlob bool, bName string, bIsBlob bool) bool {
+	a, b := aName, bName
+	if !aIsBlob {
+		a += "/"
+	}
+	if !bIsBlob {
+		b += "/"
+	}
+	return a < b
+}
+
+func buildTree(n *treeNode) *plumbing.TreeObject {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return treeEntryLess(names[i], n.children[names[i]].isBlob, names[j], n.children[names[j]].isBlob)
+	})
+
+	entries := make([]plumbing.TreeEntry, 0, len(names))
+	for _, name := range names {
+		child := n.children[name]
+
+		if child.isBlob {
+			entries = append(entries, plumbing.NewTreeEntry(gitModeToFileType(child.mode), name, child.sha, nil))
+			continue
+		}
+
+		subtree := buildTree(child)
+		entries = append(entries, plumbing.NewTreeEntry(plumbing.Dir, name, subtree.Sha, subtree))
+	}
+
+	return plumbing.NewTreeObjectFromEntries(entries)
+}
+
+// Status reports, git-status-style, how the working directory rooted at
+// root, the index, and HEAD's tree (if any commit exists yet) disagree.
+func (idx *Index) Status(root string) (string, error) {
+	headShas, err := headBlobShas(filepath.Join(root, ".git"))
+	if err != nil {
+		return "", err
+	}
+
+	byPath := make(map[string]Entry, len(idx.Entries))
+	for _, e := range idx.Entries {
+		byPath[e.Path] = e
+	}
+
+	var staged, notStaged, untracked []string
+
+	for path, e := range byPath {
+		if headSha, ok := headShas[path]; !ok {
+			staged = append(staged, fmt.Sprintf("\tnew file:   %s", path))
+		} else if !bytes.Equal(headSha, e.Sha) {
+			staged = append(staged, fmt.Sprintf("\tmodified:   %s", path))
+		}
+	}
+	for path := range headShas {
+		if _, ok := byPath[path]; !ok {
+			staged = append(staged, fmt.Sprintf("\tdeleted:    %s", path))
+		}
+	}
+
+	seen := make(map[string]bool, len(byPath))
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+
+		e, ok := byPath[rel]
+		if !ok {
+			untracked = append(untracked, fmt.Sprintf("\t%s", rel))
+			return nil
+		}
+
+		blob, err := plumbing.NewBlobObjectFromFilePath(p)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(blob.Sha, e.Sha) {
+			notStaged = append(notStaged, fmt.Sprintf("\tmodified:   %s", rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for path := range byPath {
+		if !seen[path] {
+			notStaged = append(notStaged, fmt.Sprintf("\tdeleted:    %s", path))
+		}
+	}
+
+	sort.Strings(staged)
+	sort.Strings(notStaged)
+	sort.Strings(untracked)
+
+	var s strings.Builder
+	if len(staged) > 0 {
+		fmt.Fprintln(&s, "Changes to be committed:")
+		for _, line := range staged {
+			fmt.Fprintln(&s, line)
+		}
+		fmt.Fprintln(&s)
+	}
+	if len(notStaged) > 0 {
+		fmt.Fprintln(&s, "Changes not staged for commit:")
+		for _, line := range notStaged {
+			fmt.Fprintln(&s, line)
+		}
+		fmt.Fprintln(&s)
+	}
+	if len(untracked) > 0 {
+		fmt.Fprintln(&s, "Untracked files:")
+		for _, line := range untracked {
+			fmt.Fprintln(&s, line)
+		}
+		fmt.Fprintln(&s)
+	}
+	if len(staged) == 0 && len(notStaged) == 0 && len(untracked) == 0 {
+		fmt.Fprintln(&s, "nothing to commit, working tree clean")
+	}
+
+	return s.String(), nil
+}
+
+// headBlobShas walks HEAD's commit tree (if HEAD resolves to one yet) and
+// flattens it into path -> blob sha, for diffing against the index.
+func headBlobShas(gitDir string) (map[string][]byte, error) {
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+
+	ref := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(head)), "ref:"))
+
+	hashBytes, err := os.ReadFile(filepath.Join(gitDir, ref))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string][]byte{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := plumbing.NewCommitObjectFromHash(strings.TrimSpace(string(hashBytes)))
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := plumbing.NewTreeObjectFromHash(commit.Tree.String())
+	if err != nil {
+		return nil, err
+	}
+
+	shas := make(map[string][]byte)
+	if err := collectBlobShas(tree, "", shas); err != nil {
+		return nil, err
+	}
+	return shas, nil
+}
+
+func collectBlobShas(tree *plumbing.TreeObject, prefix string, out map[string][]byte) error {
+	for _, entry := range tree.Entries {
+		name := prefix + entry.Name
+
+		if entry.Mode == plumbing.Dir {
+			subtree, err := plumbing.NewTreeObjectFromHash(entry.Sha.String())
+			if err != nil {
+				return err
+			}
+			if err := collectBlobShas(subtree, name+"/", out); err != nil {
+				return err
+			}
+			continue
+		}
+
+		out[name] = entry.Sha
+	}
+	return nil
+}
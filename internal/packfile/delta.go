@@ -0,0 +1,114 @@
+package packfile
+
+import (
+	"errors"
+)
+
+var ErrInvalidDelta = errors.New("packfile: invalid delta instruction stream")
+
+// applyDelta reconstructs an object's content from its base content and a
+// git delta instruction stream (as used by both OFS_DELTA and REF_DELTA
+// entries). The stream starts with the base and target sizes encoded as
+// little-endian base-128 varints, followed by a sequence of copy/insert
+// instructions.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != len(base) {
+		return nil, ErrInvalidDelta
+	}
+
+	targetSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	target := make([]byte, 0, targetSize)
+
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 != 0 {
+			// Copy instruction: bits 0-3 select which offset bytes follow,
+			// bits 4-6 select which size bytes follow.
+			var offset, size int
+
+			for i := 0; i < 4; i++ {
+				if op&(1<<uint(i)) != 0 {
+					if len(delta) == 0 {
+						return nil, ErrInvalidDelta
+					}
+					offset |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+
+			for i := 0; i < 3; i++ {
+				if op&(1<<uint(4+i)) != 0 {
+					if len(delta) == 0 {
+						return nil, ErrInvalidDelta
+					}
+					size |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+
+			if size == 0 {
+				size = 0x10000
+			}
+
+			if offset+size > len(base) {
+				return nil, ErrInvalidDelta
+			}
+
+			target = append(target, base[offset:offset+size]...)
+		} else if op != 0 {
+			// Insert instruction: the low 7 bits are a literal length,
+			// immediately followed by that many literal bytes.
+			n := int(op)
+			if len(delta) < n {
+				return nil, ErrInvalidDelta
+			}
+
+			target = append(target, delta[:n]...)
+			delta = delta[n:]
+		} else {
+			// Opcode 0 is reserved.
+			return nil, ErrInvalidDelta
+		}
+	}
+
+	if len(target) != targetSize {
+		return nil, ErrInvalidDelta
+	}
+
+	return target, nil
+}
+
+// readDeltaSize reads a little-endian base-128 varint (7 bits per byte, high
+// bit as the continuation flag) from the front of b and returns the decoded
+// value along with the remaining bytes.
+func readDeltaSize(b []byte) (int, []byte, error) {
+	var size, shift int
+
+	for {
+		if len(b) == 0 {
+			return 0, nil, ErrInvalidDelta
+		}
+
+		c := b[0]
+		b = b[1:]
+
+		size |= int(c&0x7f) << shift
+		shift += 7
+
+		if c&0x80 == 0 {
+			break
+		}
+	}
+
+	return size, b, nil
+}
@@ -0,0 +1,384 @@
+// Package packfile provides read-only access to git packfiles (`.pack` +
+// `.idx` v2 pairs), resolving both OFS_DELTA and REF_DELTA encoded objects
+// against their base objects.
+package packfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ObjectType mirrors the object type tag stored in a packfile entry header.
+type ObjectType int
+
+const (
+	ObjCommit   ObjectType = 1
+	ObjTree     ObjectType = 2
+	ObjBlob     ObjectType = 3
+	ObjTag      ObjectType = 4
+	ObjOfsDelta ObjectType = 6
+	ObjRefDelta ObjectType = 7
+)
+
+func (t ObjectType) String() string {
+	switch t {
+	case ObjCommit:
+		return "commit"
+	case ObjTree:
+		return "tree"
+	case ObjBlob:
+		return "blob"
+	case ObjTag:
+		return "tag"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(t))
+	}
+}
+
+var (
+	ErrObjectNotFound  = errors.New("packfile: object not found")
+	ErrInvalidIdx      = errors.New("packfile: invalid idx file")
+	ErrInvalidPack     = errors.New("packfile: invalid pack file")
+	ErrUnsupportedType = errors.New("packfile: unsupported object type")
+)
+
+var idxMagic = [4]byte{0xff, 't', 'O', 'c'}
+
+// Pack is an opened .pack/.idx pair. It indexes object locations from the
+// .idx file up front and resolves object content from the .pack file lazily,
+// one Get call at a time.
+type Pack struct {
+	packPath string
+
+	hashSize int // 20 under sha1, 32 under sha256
+	fanout   [256]uint32
+	shas     [][]byte
+	offsets  []uint64
+
+	cache map[uint64]cachedObject
+}
+
+type cachedObject struct {
+	objType ObjectType
+	content []byte
+}
+
+// Open parses the .idx file alongside packPath (which must end in ".pack")
+// and returns a Pack ready to resolve objects out of it. The pack file
+// itself is opened lazily on each Get call rather than held open.
+//
+// hashSize is the object id width in bytes for the repository's active hash
+// format (20 under sha1, 32 under sha256) — idx version 2 doesn't record its
+// own digest width, real git just writes wider entries under sha256, so the
+// caller has to say which one this pack uses.
+func Open(packPath string, hashSize int) (*Pack, error) {
+	idxPath := strings.TrimSuffix(packPath, ".pack") + ".idx"
+
+	f, err := os.Open(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic != idxMagic {
+		return nil, ErrInvalidIdx
+	}
+
+	var version uint32
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != 2 {
+		return nil, fmt.Errorf("%w: unsupported idx version %d", ErrInvalidIdx, version)
+	}
+
+	p := &Pack{packPath: packPath, hashSize: hashSize, cache: make(map[uint64]cachedObject)}
+
+	if err := binary.Read(r, binary.BigEndian, &p.fanout); err != nil {
+		return nil, err
+	}
+
+	nobj := int(p.fanout[255])
+
+	p.shas = make([][]byte, nobj)
+	for i := 0; i < nobj; i++ {
+		p.shas[i] = make([]byte, hashSize)
+		if _, err := io.ReadFull(r, p.shas[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// CRC32 table: not needed for lookups, but must be consumed to reach the
+	// offset table.
+	if _, err := io.CopyN(io.Discard, r, int64(nobj)*4); err != nil {
+		return nil, err
+	}
+
+	smallOffsets := make([]uint32, nobj)
+	if err := binary.Read(r, binary.BigEndian, &smallOffsets); err != nil {
+		return nil, err
+	}
+
+	var nlarge int
+	for _, off := range smallOffsets {
+		if off&0x80000000 != 0 {
+			nlarge++
+		}
+	}
+
+	largeOffsets := make([]uint64, nlarge)
+	if nlarge > 0 {
+		if err := binary.Read(r, binary.BigEndian, &largeOffsets); err != nil {
+			return nil, err
+		}
+	}
+
+	p.offsets = make([]uint64, nobj)
+	for i, off := range smallOffsets {
+		if off&0x80000000 != 0 {
+			largeIdx := off & 0x7fffffff
+			if int(largeIdx) >= len(largeOffsets) {
+				return nil, fmt.Errorf("%w: large offset index %d out of range", ErrInvalidIdx, largeIdx)
+			}
+			p.offsets[i] = largeOffsets[largeIdx]
+		} else {
+			p.offsets[i] = uint64(off)
+		}
+	}
+
+	return p, nil
+}
+
+// Find returns the pack offset of the object identified by the given hex
+// string (a sha1 or sha256 hash, matching this pack's idx digest width),
+// using the idx fanout table for an O(log n) lookup.
+func (p *Pack) Find(hash string) (uint64, bool) {
+	raw, err := hex.DecodeString(hash)
+	if err != nil || len(raw) != p.hashSize {
+		return 0, false
+	}
+
+	var lo int
+	if raw[0] > 0 {
+		lo = int(p.fanout[raw[0]-1])
+	}
+	hi := int(p.fanout[raw[0]])
+
+	idx := sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.shas[lo+i], raw) >= 0
+	})
+	idx += lo
+	if idx >= hi || !bytes.Equal(p.shas[idx], raw) {
+		return 0, false
+	}
+
+	return p.offsets[idx], true
+}
+
+// Get resolves the object identified by hash, applying any delta chain
+// needed, and returns its final type and decompressed content.
+func (p *Pack) Get(hash string) (ObjectType, []byte, error) {
+	offset, ok := p.Find(hash)
+	if !ok {
+		return 0, nil, ErrObjectNotFound
+	}
+
+	f, err := os.Open(p.packPath)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	return p.readAt(f, offset)
+}
+
+// readAt reads and fully resolves (including delta application) the object
+// stored at the given offset in the pack file.
+func (p *Pack) readAt(f *os.File, offset uint64) (ObjectType, []byte, error) {
+	if cached, ok := p.cache[offset]; ok {
+		return cached.objType, cached.content, nil
+	}
+
+	if _, err := f.Seek(int64(offset), io.SeekStart); err != nil {
+		return 0, nil, err
+	}
+
+	r := bufio.NewReader(f)
+
+	objType, size, err := readTypeAndSize(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	switch objType {
+	case ObjOfsDelta:
+		negOffset, err := readOfsDeltaOffset(r)
+		if err != nil {
+			return 0, nil, err
+		}
+		baseOffset := offset - negOffset
+
+		delta, err := inflate(r, size)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		baseType, base, err := p.readAt(f, baseOffset)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		content, err := applyDelta(base, delta)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		p.cache[offset] = cachedObject{baseType, content}
+		return baseType, content, nil
+
+	case ObjRefDelta:
+		baseSha := make([]byte, p.hashSize)
+		if _, err := io.ReadFull(r, baseSha); err != nil {
+			return 0, nil, err
+		}
+
+		delta, err := inflate(r, size)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		baseType, base, err := p.Get(hex.EncodeToString(baseSha))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		content, err := applyDelta(base, delta)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		p.cache[offset] = cachedObject{baseType, content}
+		return baseType, content, nil
+
+	case ObjCommit, ObjTree, ObjBlob, ObjTag:
+		content, err := inflate(r, size)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		p.cache[offset] = cachedObject{objType, content}
+		return objType, content, nil
+
+	default:
+		return 0, nil, ErrUnsupportedType
+	}
+}
+
+// readTypeAndSize reads a packfile entry header: a 3-bit type tag in bits
+// 4-6 of the first byte, and an object size assembled little-endian from the
+// low 4 bits of the first byte plus 7 bits from each continuation byte.
+func readTypeAndSize(r io.ByteReader) (ObjectType, int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	objType := ObjectType((b >> 4) & 0x07)
+	size := int(b & 0x0f)
+	shift := 4
+
+	for b&0x80 != 0 {
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		size |= int(b&0x7f) << shift
+		shift += 7
+	}
+
+	return objType, size, nil
+}
+
+// readOfsDeltaOffset reads the OFS_DELTA big-endian varint negative offset:
+// n = (n+1)<<7 | (b&0x7f) for each continuation byte.
+func readOfsDeltaOffset(r io.ByteReader) (uint64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	n := uint64(b & 0x7f)
+	for b&0x80 != 0 {
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n = (n+1)<<7 | uint64(b&0x7f)
+	}
+
+	return n, nil
+}
+
+// inflate zlib-decompresses exactly one object payload from r. size is the
+// expected decompressed length as declared in the entry header and is used
+// only to sanity-check the result.
+func inflate(r io.Reader, size int) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) != size {
+		return nil, fmt.Errorf("%w: expected %d decompressed bytes, got %d", ErrInvalidPack, size, len(content))
+	}
+
+	return content, nil
+}
+
+// FindObject searches every packfile under packDir for hash and returns its
+// resolved type and content. It is the fallback used once a loose object
+// file is missing from .git/objects. hashSize is the repository's active
+// object id width in bytes (20 under sha1, 32 under sha256).
+func FindObject(packDir string, hash string, hashSize int) (ObjectType, []byte, error) {
+	matches, err := filepath.Glob(filepath.Join(packDir, "*.idx"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, idxPath := range matches {
+		packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+		pack, err := Open(packPath, hashSize)
+		if err != nil {
+			continue
+		}
+
+		objType, content, err := pack.Get(hash)
+		if err == nil {
+			return objType, content, nil
+		}
+	}
+
+	return 0, nil, ErrObjectNotFound
+}
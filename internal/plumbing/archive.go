@@ -0,0 +1,193 @@
+package plumbing
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// ArchiveFormat selects the container format WriteArchive streams into.
+type ArchiveFormat string
+
+const (
+	Tar   ArchiveFormat = "tar"
+	TarGz ArchiveFormat = "tar.gz"
+	Zip   ArchiveFormat = "zip"
+)
+
+var ErrUnsupportedArchiveFormat = fmt.Errorf("unsupported archive format")
+
+// WriteArchive recursively walks the tree identified by hash and streams it
+// to w as a tar, tar.gz, or zip archive, prefixing every entry path with
+// prefix. Blob content streams directly from the object store without
+// buffering the whole archive in memory.
+func WriteArchive(w io.Writer, hash string, format ArchiveFormat, prefix string, opts ...Option) error {
+	tree, err := NewTreeObjectFromHash(hash, opts...)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case Tar:
+		tw := tar.NewWriter(w)
+		if err := writeTarTree(tw, tree, prefix, opts); err != nil {
+			tw.Close()
+			return err
+		}
+		return tw.Close()
+
+	case TarGz:
+		gw := gzip.NewWriter(w)
+		tw := tar.NewWriter(gw)
+		if err := writeTarTree(tw, tree, prefix, opts); err != nil {
+			tw.Close()
+			gw.Close()
+			return err
+		}
+		if err := tw.Close(); err != nil {
+			gw.Close()
+			return err
+		}
+		return gw.Close()
+
+	case Zip:
+		zw := zip.NewWriter(w)
+		if err := writeZipTree(zw, tree, prefix, opts); err != nil {
+			zw.Close()
+			return err
+		}
+		return zw.Close()
+
+	default:
+		return ErrUnsupportedArchiveFormat
+	}
+}
+
+func writeTarTree(tw *tar.Writer, tree *TreeObject, prefix string, opts []Option) error {
+	for _, entry := range tree.Entries {
+		name := prefix + entry.Name
+
+		switch entry.Mode {
+		case Dir:
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     name + "/",
+				Mode:     0755,
+			}); err != nil {
+				return err
+			}
+
+			subtree, err := NewTreeObjectFromHash(entry.Sha.String(), opts...)
+			if err != nil {
+				return err
+			}
+
+			if err := writeTarTree(tw, subtree, name+"/", opts); err != nil {
+				return err
+			}
+
+		case Symlink:
+			blob, err := NewBlobObjectFromHash(entry.Sha.String(), opts...)
+			if err != nil {
+				return err
+			}
+
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeSymlink,
+				Name:     name,
+				Linkname: string(blob.Content),
+				Mode:     0777,
+			}); err != nil {
+				return err
+			}
+
+		default:
+			blob, err := NewBlobObjectFromHash(entry.Sha.String(), opts...)
+			if err != nil {
+				return err
+			}
+
+			mode := int64(0644)
+			if entry.Mode == Executable {
+				mode = 0755
+			}
+
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     name,
+				Size:     int64(blob.Size),
+				Mode:     mode,
+			}); err != nil {
+				return err
+			}
+
+			if _, err := tw.Write(blob.Content); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeZipTree(zw *zip.Writer, tree *TreeObject, prefix string, opts []Option) error {
+	for _, entry := range tree.Entries {
+		name := prefix + entry.Name
+
+		switch entry.Mode {
+		case Dir:
+			if _, err := zw.Create(name + "/"); err != nil {
+				return err
+			}
+
+			subtree, err := NewTreeObjectFromHash(entry.Sha.String(), opts...)
+			if err != nil {
+				return err
+			}
+
+			if err := writeZipTree(zw, subtree, name+"/", opts); err != nil {
+				return err
+			}
+
+		case Symlink:
+			blob, err := NewBlobObjectFromHash(entry.Sha.String(), opts...)
+			if err != nil {
+				return err
+			}
+
+			fh := &zip.FileHeader{Name: name, Method: zip.Store}
+			fh.SetMode(fileTypeToFileMode(Symlink))
+
+			w, err := zw.CreateHeader(fh)
+			if err != nil {
+				return err
+			}
+
+			if _, err := w.Write(blob.Content); err != nil {
+				return err
+			}
+
+		default:
+			blob, err := NewBlobObjectFromHash(entry.Sha.String(), opts...)
+			if err != nil {
+				return err
+			}
+
+			fh := &zip.FileHeader{Name: name, Method: zip.Deflate}
+			fh.SetMode(fileTypeToFileMode(entry.Mode))
+
+			w, err := zw.CreateHeader(fh)
+			if err != nil {
+				return err
+			}
+
+			if _, err := w.Write(blob.Content); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
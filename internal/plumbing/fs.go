@@ -0,0 +1,295 @@
+package plumbing
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var errNotDir = errors.New("not a directory")
+
+// gitFS is an io/fs.FS view over a TreeObject, rooted wherever NewFS was
+// pointed. Sub-trees are deserialized lazily, each time a path beneath them
+// is opened; they are not cached between calls.
+type gitFS struct {
+	root *TreeObject
+	opts []Option
+}
+
+// NewFS returns an io/fs.FS backed by the tree identified by hash. If hash
+// names a commit instead, the commit's tree is used as the root. Directory
+// entries are resolved into sub-trees lazily and file content streams
+// directly from the underlying blob.
+func NewFS(hash string, opts ...Option) (fs.FS, error) {
+	meta, err := GetGitObjectMetadata(hash, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch meta.Header {
+	case Tree:
+		tree, err := NewTreeObjectFromHash(hash, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &gitFS{root: tree, opts: opts}, nil
+
+	case Commit:
+		commit, err := NewCommitObjectFromHash(hash, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		tree, err := NewTreeObjectFromHash(commit.Tree.String(), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &gitFS{root: tree, opts: opts}, nil
+
+	default:
+		return nil, ErrInvalidGitObject
+	}
+}
+
+// lookup resolves name to the TreeEntry it names, deserializing any
+// intermediate sub-trees along the way. The second return value is false
+// when name does not exist.
+func (g *gitFS) lookup(name string) (TreeEntry, bool, error) {
+	tree := g.root
+	parts := strings.Split(name, "/")
+
+	for i, part := range parts {
+		entry, ok := findTreeEntry(tree, part)
+		if !ok {
+			return TreeEntry{}, false, nil
+		}
+
+		if i == len(parts)-1 {
+			return entry, true, nil
+		}
+
+		if entry.Mode != Dir {
+			return TreeEntry{}, false, nil
+		}
+
+		sub, err := NewTreeObjectFromHash(entry.Sha.String(), g.opts...)
+		if err != nil {
+			return TreeEntry{}, false, err
+		}
+		tree = sub
+	}
+
+	return TreeEntry{}, false, nil
+}
+
+func findTreeEntry(t *TreeObject, name string) (TreeEntry, bool) {
+	for _, entry := range t.Entries {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return TreeEntry{}, false
+}
+
+func (g *gitFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		return &gitDir{name: name, tree: g.root, opts: g.opts}, nil
+	}
+
+	entry, ok, err := g.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if entry.Mode == Dir {
+		sub, err := NewTreeObjectFromHash(entry.Sha.String(), g.opts...)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &gitDir{name: name, tree: sub, opts: g.opts}, nil
+	}
+
+	blob, err := NewBlobObjectFromHash(entry.Sha.String(), g.opts...)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	info := gitFileInfo{
+		name: path.Base(name),
+		size: int64(blob.Size),
+		mode: fileTypeToFileMode(entry.Mode),
+	}
+	return &gitFile{info: info, r: bytes.NewReader(blob.Content)}, nil
+}
+
+func (g *gitFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	file, err := g.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	return dir.ReadDir(-1)
+}
+
+func (g *gitFS) ReadFile(name string) ([]byte, error) {
+	file, err := g.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	blob, ok := file.(*gitFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")}
+	}
+
+	content := make([]byte, blob.info.size)
+	_, err = blob.r.ReadAt(content, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+func (g *gitFS) Stat(name string) (fs.FileInfo, error) {
+	file, err := g.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return file.Stat()
+}
+
+// fileTypeToFileMode derives the io/fs.FileMode for a git file mode.
+func fileTypeToFileMode(ft FileType) fs.FileMode {
+	switch ft {
+	case Dir:
+		return fs.ModeDir | 0755
+	case Symlink:
+		return fs.ModeSymlink | 0777
+	case Executable:
+		return 0755
+	default:
+		return 0644
+	}
+}
+
+type gitFileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (i gitFileInfo) Name() string       { return i.name }
+func (i gitFileInfo) Size() int64        { return i.size }
+func (i gitFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i gitFileInfo) ModTime() time.Time { return time.Time{} }
+func (i gitFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i gitFileInfo) Sys() any           { return nil }
+
+// gitDirEntry adapts a TreeEntry to fs.DirEntry, fetching the blob size for
+// Info() lazily only when asked.
+type gitDirEntry struct {
+	entry TreeEntry
+	opts  []Option
+}
+
+func (d gitDirEntry) Name() string { return d.entry.Name }
+func (d gitDirEntry) IsDir() bool  { return d.entry.Mode == Dir }
+func (d gitDirEntry) Type() fs.FileMode {
+	return fileTypeToFileMode(d.entry.Mode).Type()
+}
+
+func (d gitDirEntry) Info() (fs.FileInfo, error) {
+	mode := fileTypeToFileMode(d.entry.Mode)
+
+	var size int64
+	if d.entry.Mode != Dir {
+		meta, err := GetGitObjectMetadata(d.entry.Sha.String(), d.opts...)
+		if err != nil {
+			return nil, err
+		}
+		size = int64(meta.Size)
+	}
+
+	return gitFileInfo{name: d.entry.Name, size: size, mode: mode}, nil
+}
+
+// gitDir implements fs.ReadDirFile over a lazily deserialized TreeObject.
+type gitDir struct {
+	name    string
+	tree    *TreeObject
+	opts    []Option
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *gitDir) Stat() (fs.FileInfo, error) {
+	return gitFileInfo{name: path.Base(d.name), mode: fs.ModeDir | 0755}, nil
+}
+
+func (d *gitDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *gitDir) Close() error { return nil }
+
+func (d *gitDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		for _, entry := range d.tree.Entries {
+			d.entries = append(d.entries, gitDirEntry{entry: entry, opts: d.opts})
+		}
+		sort.Slice(d.entries, func(i, j int) bool {
+			return d.entries[i].Name() < d.entries[j].Name()
+		})
+	}
+
+	if n <= 0 {
+		rest := d.entries[d.offset:]
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+
+	if d.offset >= len(d.entries) {
+		return nil, io.EOF
+	}
+
+	end := d.offset + n
+	if end > len(d.entries) {
+		end = len(d.entries)
+	}
+
+	page := d.entries[d.offset:end]
+	d.offset = end
+	return page, nil
+}
+
+// gitFile implements fs.File over a blob's content.
+type gitFile struct {
+	info gitFileInfo
+	r    *bytes.Reader
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *gitFile) Read(p []byte) (int, error) { return f.r.Read(p) }
+func (f *gitFile) Close() error               { return nil }
@@ -0,0 +1,51 @@
+package plumbing
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+)
+
+// Hash is a git object id: 20 bytes under the sha1 object format, 32 bytes
+// under sha256. Unlike go-git's fixed-size plumbing.Hash, this one is
+// polymorphic across object formats, since a Go array can't vary in size.
+type Hash []byte
+
+// Size returns the digest length in bytes.
+func (h Hash) Size() int { return len(h) }
+
+// String hex-encodes the digest, the same form object paths and commit
+// headers use.
+func (h Hash) String() string { return hex.EncodeToString(h) }
+
+// ObjectFormat selects the hash algorithm a repository's objects are
+// identified by, read from .git/config's extensions.objectFormat. It
+// defaults to SHA1, matching every repository created before SHA-256
+// support existed.
+type ObjectFormat string
+
+const (
+	SHA1   ObjectFormat = "sha1"
+	SHA256 ObjectFormat = "sha256"
+)
+
+// Size returns the digest length in bytes for the format.
+func (f ObjectFormat) Size() int {
+	if f == SHA256 {
+		return 32
+	}
+	return 20
+}
+
+// HexSize returns the hex-encoded digest length for the format.
+func (f ObjectFormat) HexSize() int {
+	return f.Size() * 2
+}
+
+func (f ObjectFormat) newHash() hash.Hash {
+	if f == SHA256 {
+		return sha256.New()
+	}
+	return sha1.New()
+}
@@ -5,7 +5,7 @@ package plumbing
 import (
 	"bytes"
 	"compress/zlib"
-	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -17,12 +17,14 @@ import (
 type ObjectType string
 
 const (
-	Blob ObjectType = "blob"
-	Tree            = "tree"
+	Blob   ObjectType = "blob"
+	Tree              = "tree"
+	Commit            = "commit"
 )
 
 var ErrInvalidBlobObject = errors.New("Invalid blob object: raw binary data does not represent a blob.")
 var ErrInvalidTreeObject = errors.New("Invalid tree object: raw binary data does not represent a tree.")
+var ErrInvalidCommitObject = errors.New("Invalid commit object: raw binary data does not represent a commit.")
 var ErrInvalidGitObject = errors.New("Invalid git object.")
 
 type FileType string
@@ -72,7 +74,8 @@ type BlobObject struct {
 	Mode    FileType
 	Size    int
 	Content []byte
-	Sha     []byte
+	Sha     Hash
+	store   ObjectStore
 }
 
 func (b BlobObject) String() string {
@@ -98,20 +101,17 @@ func (b *BlobObject) serializeCompressed() []byte {
 }
 
 func (b *BlobObject) WriteToFile() error {
-	blob := b.serializeCompressed()
-	dir, file := b.Sha[0], b.Sha[1:]
-	err := os.MkdirAll(fmt.Sprintf("./.git/objects/%x", dir), 0755)
-	if err != nil {
-		return err
+	store := b.store
+	if store == nil {
+		store = defaultStore
 	}
-	fn := fmt.Sprintf("./.git/objects/%x/%x", dir, file)
-	return os.WriteFile(fn, blob, 0444)
+	return store.Put(b.Sha.String(), b.Header, b.serializeCompressed())
 }
 
 // NewBlobObjectFromFilePath takes in the path to a file and returns a blob object
 // representation of the file and nil if conversion was succesful. Otherwise,
 // nil and an error value is returned.
-func NewBlobObjectFromFilePath(filePath string) (*BlobObject, error) {
+func NewBlobObjectFromFilePath(filePath string, opts ...Option) (*BlobObject, error) {
 	info, err := os.Lstat(filePath)
 	if err != nil {
 		return nil, err
@@ -137,39 +137,37 @@ func NewBlobObjectFromFilePath(filePath string) (*BlobObject, error) {
 	blob.WriteString(fmt.Sprintf("blob %d\x00", len(content)))
 	blob.Write(content)
 
-	return deserializeDecompressedBlobObject(blob.Bytes())
-}
-
-// NewBlobObjectFromHash takes the sha1 hex string of the blob object and
-// returns a (*BlobObject, nil) on success. Otherwise, (nil, error).
-func NewBlobObjectFromHash(hash string) (*BlobObject, error) {
-	fn := fmt.Sprintf("./.git/objects/%s/%s", hash[:2], hash[2:])
-	b, err := os.ReadFile(fn)
+	b, err := deserializeDecompressedBlobObject(blob.Bytes(), opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	r := bytes.NewReader(b)
-
-	return deserializeCompressedBlobObject(r)
+	b.store = resolveOptions(opts).store
+	return b, nil
 }
-func deserializeCompressedBlobObject(r io.Reader) (*BlobObject, error) {
-	b, err := zlib.NewReader(r)
-	defer b.Close()
 
+// NewBlobObjectFromHash takes the hex string of the blob object's hash (40
+// characters under sha1, 64 under sha256) and returns a (*BlobObject, nil)
+// on success. Otherwise, (nil, error).
+//
+// The object is looked up in the configured ObjectStore first, falling back
+// to any packfile the store exposes when no loose object is found.
+func NewBlobObjectFromHash(hash string, opts ...Option) (*BlobObject, error) {
+	_, decompressed, err := readRawObject(hash, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	blob, err := io.ReadAll(b)
+	b, err := deserializeDecompressedBlobObject(decompressed, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	return deserializeDecompressedBlobObject(blob)
+	b.store = resolveOptions(opts).store
+	return b, nil
 }
 
-func deserializeDecompressedBlobObject(b []byte) (*BlobObject, error) {
+func deserializeDecompressedBlobObject(b []byte, opts ...Option) (*BlobObject, error) {
 	first, rest, ok := bytes.Cut(b, []byte(" "))
 	if !ok {
 		return nil, ErrInvalidBlobObject
@@ -187,7 +185,7 @@ func deserializeDecompressedBlobObject(b []byte) (*BlobObject, error) {
 		return nil, err
 	}
 
-	h := sha1.New()
+	h := resolveOptions(opts).format.newHash()
 	h.Write(b)
 	hash := h.Sum(nil)
 
@@ -204,7 +202,7 @@ type TreeEntry struct {
 	Header ObjectType
 	Mode   FileType
 	Name   string
-	Sha    []byte
+	Sha    Hash
 	w      FileWriter
 }
 
@@ -213,14 +211,35 @@ func (t *TreeEntry) WriteToFile() error {
 }
 
 func (e TreeEntry) String() string {
-	return fmt.Sprintf("%06s %s %x    %s", e.Mode, e.Header, e.Sha, e.Name)
+	return fmt.Sprintf("%06s %s %s    %s", e.Mode, e.Header, e.Sha, e.Name)
+}
+
+type noopFileWriter struct{}
+
+func (noopFileWriter) WriteToFile() error { return nil }
+
+// NewTreeEntry builds a TreeEntry for mode/name/sha. Pass the FileWriter
+// responsible for persisting the referenced object (a *BlobObject or
+// *TreeObject) so TreeObject.WriteToFile can cascade into it, or nil when
+// the object is already known to exist in the store, e.g. a blob staged via
+// `git add`.
+func NewTreeEntry(mode FileType, name string, sha []byte, w FileWriter) TreeEntry {
+	header := Blob
+	if mode == Dir {
+		header = Tree
+	}
+	if w == nil {
+		w = noopFileWriter{}
+	}
+	return TreeEntry{Header: header, Mode: mode, Name: name, Sha: sha, w: w}
 }
 
 type TreeObject struct {
 	Header  ObjectType
 	Entries []TreeEntry
 	Size    int
-	Sha     []byte
+	Sha     Hash
+	store   ObjectStore
 }
 
 func (t *TreeObject) StringNameOnly() string {
@@ -271,21 +290,18 @@ func (t *TreeObject) WriteToFile() error {
 		}
 	}
 
-	tree := t.serializeCompressed()
-	dir, file := t.Sha[0], t.Sha[1:]
-	err := os.MkdirAll(fmt.Sprintf("./.git/objects/%x", dir), 0755)
-	if err != nil {
-		return err
+	store := t.store
+	if store == nil {
+		store = defaultStore
 	}
-	fn := fmt.Sprintf("./.git/objects/%x/%x", dir, file)
-	return os.WriteFile(fn, tree, 4644)
+	return store.Put(t.Sha.String(), t.Header, t.serializeCompressed())
 }
 
 // NewTreeObjectFromFilePath takes the base dir path as argument and creates all
 // a TreeObject, along with all its sub TreeObject(s) and BlobObject(s). The
 // function returns a (*TreeObject, nil) on success. Oterwise, it returns (nil,
 // error).
-func NewTreeObjectFromFilePath(dirPath string) (*TreeObject, error) {
+func NewTreeObjectFromFilePath(dirPath string, opts ...Option) (*TreeObject, error) {
 	files, err := os.ReadDir(dirPath)
 	if err != nil {
 		return nil, err
@@ -294,6 +310,7 @@ func NewTreeObjectFromFilePath(dirPath string) (*TreeObject, error) {
 	var t TreeObject
 	var tree, content bytes.Buffer
 	t.Header = Tree
+	t.store = resolveOptions(opts).store
 
 	for _, file := range files {
 		if file.Name() == ".git" {
@@ -305,7 +322,7 @@ func NewTreeObjectFromFilePath(dirPath string) (*TreeObject, error) {
 			entry.Header = Tree
 			entry.Mode = Dir
 
-			subtree, err := NewTreeObjectFromFilePath(dirPath + "/" + file.Name())
+			subtree, err := NewTreeObjectFromFilePath(dirPath+"/"+file.Name(), opts...)
 			if err != nil {
 				return nil, err
 			}
@@ -314,7 +331,7 @@ func NewTreeObjectFromFilePath(dirPath string) (*TreeObject, error) {
 			entry.Sha = subtree.Sha
 		} else {
 			entry.Header = Blob
-			blob, err := NewBlobObjectFromFilePath(dirPath + "/" + file.Name())
+			blob, err := NewBlobObjectFromFilePath(dirPath+"/"+file.Name(), opts...)
 			if err != nil {
 				return nil, err
 			}
@@ -338,35 +355,59 @@ func NewTreeObjectFromFilePath(dirPath string) (*TreeObject, error) {
 	tree.WriteString(fmt.Sprintf("%s %d\x00", t.Header, content.Len()))
 	tree.Write(content.Bytes())
 
-	h := sha1.New()
+	h := resolveOptions(opts).format.newHash()
 	h.Write(tree.Bytes())
 	hash := h.Sum(nil)
 	t.Sha = hash
 	return &t, nil
 }
 
-// NewTreeObjectFromHash takes the sha1 hex string of the blob object and
-// returns a (*TreeObject, nil) on success. Otherwise, (nil, error).
+// NewTreeObjectFromHash takes the hex string of the tree object's hash (40
+// characters under sha1, 64 under sha256) and returns a (*TreeObject, nil)
+// on success. Otherwise, (nil, error).
 //
 // Also, this function does not create any subsequent objects for any of the
 // entries in the tree object.
-func NewTreeObjectFromHash(hash string) (*TreeObject, error) {
-	fn := fmt.Sprintf("./.git/objects/%s/%s", hash[:2], hash[2:])
-	b, err := os.ReadFile(fn)
+//
+// The object is looked up in the configured ObjectStore first, falling back
+// to any packfile the store exposes when no loose object is found.
+func NewTreeObjectFromHash(hash string, opts ...Option) (*TreeObject, error) {
+	_, decompressed, err := readRawObject(hash, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := deserializeDecompressedTreeObject(decompressed, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	r := bytes.NewReader(b)
+	t.store = resolveOptions(opts).store
+	return t, nil
+}
+
+// NewTreeObjectFromEntries builds a TreeObject, with its Sha already
+// computed, out of pre-built entries. Unlike NewTreeObjectFromFilePath, it
+// doesn't walk a working directory — it's for callers assembling a tree
+// from some other source, like a staging index.
+func NewTreeObjectFromEntries(entries []TreeEntry, opts ...Option) *TreeObject {
+	var t TreeObject
+	t.Header = Tree
+	t.Entries = entries
+	t.store = resolveOptions(opts).store
+
+	h := resolveOptions(opts).format.newHash()
+	h.Write(t.serializeDecompressed())
+	t.Sha = h.Sum(nil)
 
-	return deserializeCompressedTreeObject(r)
+	return &t
 }
 
 // LsTree lists the entries of a tree object. It takes in a sha1 hex string and
 // an nameOnly bool. It returns the output string and nil on success. Otherwise,
 // it returns ("", nil).
-func LsTree(hash string, nameOnly bool) (string, error) {
-	tree, err := NewTreeObjectFromHash(hash)
+func LsTree(hash string, nameOnly bool, opts ...Option) (string, error) {
+	tree, err := NewTreeObjectFromHash(hash, opts...)
 
 	if err != nil {
 		return "", err
@@ -379,23 +420,7 @@ func LsTree(hash string, nameOnly bool) (string, error) {
 	}
 }
 
-func deserializeCompressedTreeObject(r io.Reader) (*TreeObject, error) {
-	t, err := zlib.NewReader(r)
-	defer t.Close()
-
-	if err != nil {
-		return nil, err
-	}
-
-	tree, err := io.ReadAll(t)
-	if err != nil {
-		return nil, err
-	}
-
-	return deserializeDecompressedTreeObject(tree)
-}
-
-func deserializeDecompressedTreeObject(b []byte) (*TreeObject, error) {
+func deserializeDecompressedTreeObject(b []byte, opts ...Option) (*TreeObject, error) {
 	first, content, ok := bytes.Cut(b, []byte("\x00"))
 	meta := bytes.Split(first, []byte(" "))
 	if !ok || len(meta) != 2 {
@@ -408,6 +433,8 @@ func deserializeDecompressedTreeObject(b []byte) (*TreeObject, error) {
 		return nil, ErrInvalidTreeObject
 	}
 
+	shaSize := resolveOptions(opts).format.Size()
+
 	var t TreeObject
 	t.Header = Tree
 	t.Size = size
@@ -434,17 +461,17 @@ func deserializeDecompressedTreeObject(b []byte) (*TreeObject, error) {
 
 		entry.Name = string(name)
 
-		if len(content) == 20 {
+		if len(content) == shaSize {
 			entry.Sha = content
 			ok = false
 		} else {
-			entry.Sha = content[:20]
-			content = content[20:]
+			entry.Sha = content[:shaSize]
+			content = content[shaSize:]
 		}
 		t.Entries = append(t.Entries, entry)
 	}
 
-	h := sha1.New()
+	h := resolveOptions(opts).format.newHash()
 	h.Write(b)
 	hash := h.Sum(nil)
 	t.Sha = hash
@@ -452,25 +479,195 @@ func deserializeDecompressedTreeObject(b []byte) (*TreeObject, error) {
 	return &t, nil
 }
 
-// GetGitObjectMetadata takes a sha1 hex string and returns the metadata of the
-// git object represented by the hash. It return (GitObjectMetada, error) on
-// success. Otherwise, it returns (nil, error).
-func GetGitObjectMetadata(hash string) (GitObjectMetadata, error) {
-	var meta GitObjectMetadata
-	fn := fmt.Sprintf("./.git/objects/%s/%s", hash[:2], hash[2:])
-	b, err := os.ReadFile(fn)
+type CommitObject struct {
+	Header    ObjectType
+	Size      int
+	Tree      Hash
+	Parents   []Hash
+	Author    string
+	Committer string
+	Message   string
+	Sha       Hash
+	store     ObjectStore
+}
+
+func (c CommitObject) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "tree %s\n", c.Tree)
+	for _, parent := range c.Parents {
+		fmt.Fprintf(&s, "parent %s\n", parent)
+	}
+	fmt.Fprintf(&s, "author %s\n", c.Author)
+	fmt.Fprintf(&s, "committer %s\n", c.Committer)
+	fmt.Fprintf(&s, "\n%s\n", c.Message)
+	return s.String()
+}
+
+func (c *CommitObject) serializeDecompressed() []byte {
+	var commit, content bytes.Buffer
+
+	fmt.Fprintf(&content, "tree %s\n", c.Tree)
+	for _, parent := range c.Parents {
+		fmt.Fprintf(&content, "parent %s\n", parent)
+	}
+	fmt.Fprintf(&content, "author %s\n", c.Author)
+	fmt.Fprintf(&content, "committer %s\n", c.Committer)
+	fmt.Fprintf(&content, "\n%s\n", c.Message)
+
+	commit.WriteString(fmt.Sprintf("%s %d\x00", c.Header, content.Len()))
+	commit.Write(content.Bytes())
+
+	return commit.Bytes()
+}
+
+func (c *CommitObject) serializeCompressed() []byte {
+	commit := c.serializeDecompressed()
+
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write(commit)
+	w.Close()
+
+	return compressed.Bytes()
+}
+
+func (c *CommitObject) WriteToFile() error {
+	store := c.store
+	if store == nil {
+		store = defaultStore
+	}
+	return store.Put(c.Sha.String(), c.Header, c.serializeCompressed())
+}
+
+// NewCommitObject takes a tree sha, a list of parent shas, the author and
+// committer lines (formatted as "name <email> timestamp timezone", the same
+// way real git writes them) and a commit message. It returns a
+// (*CommitObject, nil) with its Sha already computed on success. Otherwise,
+// it returns (nil, error).
+func NewCommitObject(tree []byte, parents [][]byte, author, committer, message string, opts ...Option) (*CommitObject, error) {
+	var commit, content bytes.Buffer
+
+	fmt.Fprintf(&content, "tree %x\n", tree)
+	for _, parent := range parents {
+		fmt.Fprintf(&content, "parent %x\n", parent)
+	}
+	fmt.Fprintf(&content, "author %s\n", author)
+	fmt.Fprintf(&content, "committer %s\n", committer)
+	fmt.Fprintf(&content, "\n%s\n", message)
+
+	commit.WriteString(fmt.Sprintf("%s %d\x00", Commit, content.Len()))
+	commit.Write(content.Bytes())
+
+	c, err := deserializeDecompressedCommitObject(commit.Bytes(), opts...)
 	if err != nil {
-		return meta, err
+		return nil, err
 	}
 
-	buf := bytes.NewBuffer(b)
-	r, err := zlib.NewReader(buf)
+	c.store = resolveOptions(opts).store
+	return c, nil
+}
+
+// NewCommitObjectFromHash takes the hex string of the commit object's hash
+// (40 characters under sha1, 64 under sha256) and returns a (*CommitObject,
+// nil) on success. Otherwise, (nil, error).
+//
+// The object is looked up in the configured ObjectStore first, falling back
+// to any packfile the store exposes when no loose object is found.
+func NewCommitObjectFromHash(hash string, opts ...Option) (*CommitObject, error) {
+	_, decompressed, err := readRawObject(hash, opts...)
 	if err != nil {
-		return meta, err
+		return nil, err
+	}
+
+	c, err := deserializeDecompressedCommitObject(decompressed, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store = resolveOptions(opts).store
+	return c, nil
+}
+
+func deserializeDecompressedCommitObject(b []byte, opts ...Option) (*CommitObject, error) {
+	first, content, ok := bytes.Cut(b, []byte("\x00"))
+	meta := bytes.Split(first, []byte(" "))
+	if !ok || len(meta) != 2 {
+		return nil, ErrInvalidCommitObject
+	}
+
+	header := ObjectType(meta[0])
+	size, err := strconv.Atoi(string(meta[1]))
+	if err != nil || header != Commit || size != len(content) {
+		return nil, ErrInvalidCommitObject
+	}
+
+	header_, message, ok := bytes.Cut(content, []byte("\n\n"))
+	if !ok {
+		return nil, ErrInvalidCommitObject
+	}
+
+	var c CommitObject
+	c.Header = Commit
+	c.Size = size
+	c.Message = strings.TrimSuffix(string(message), "\n")
+
+	for _, line := range bytes.Split(header_, []byte("\n")) {
+		// A line starting with a space is a continuation of the previous
+		// header (e.g. the remaining lines of a multi-line "gpgsig" or
+		// "mergetag"), not a new one; we don't model those headers, so
+		// there's nothing to append it to.
+		if bytes.HasPrefix(line, []byte(" ")) {
+			continue
+		}
+
+		key, value, ok := bytes.Cut(line, []byte(" "))
+		if !ok {
+			return nil, ErrInvalidCommitObject
+		}
+
+		switch string(key) {
+		case "tree":
+			treeSha := make([]byte, hex.DecodedLen(len(value)))
+			if _, err := hex.Decode(treeSha, value); err != nil {
+				return nil, ErrInvalidCommitObject
+			}
+			c.Tree = treeSha
+
+		case "parent":
+			parentSha := make([]byte, hex.DecodedLen(len(value)))
+			if _, err := hex.Decode(parentSha, value); err != nil {
+				return nil, ErrInvalidCommitObject
+			}
+			c.Parents = append(c.Parents, parentSha)
+
+		case "author":
+			c.Author = string(value)
+
+		case "committer":
+			c.Committer = string(value)
+
+		default:
+			// Unknown headers (encoding, gpgsig, mergetag, ...) are common
+			// on real-world commits; we don't model them, so just skip.
+		}
 	}
-	defer r.Close()
 
-	decompressed, err := io.ReadAll(r)
+	h := resolveOptions(opts).format.newHash()
+	h.Write(b)
+	hash := h.Sum(nil)
+	c.Sha = hash
+
+	return &c, nil
+}
+
+// GetGitObjectMetadata takes the hex string of an object's hash (40
+// characters under sha1, 64 under sha256) and returns the metadata of the
+// git object represented by the hash. It returns (GitObjectMetadata, nil) on
+// success. Otherwise, it returns a zero GitObjectMetadata and the error.
+func GetGitObjectMetadata(hash string, opts ...Option) (GitObjectMetadata, error) {
+	var meta GitObjectMetadata
+
+	_, decompressed, err := readRawObject(hash, opts...)
 	if err != nil {
 		return meta, err
 	}
@@ -486,7 +683,7 @@ func GetGitObjectMetadata(hash string) (GitObjectMetadata, error) {
 	}
 
 	header := ObjectType(parts[0])
-	if header != Tree && header != Blob {
+	if header != Tree && header != Blob && header != Commit {
 		return meta, ErrInvalidGitObject
 	}
 
@@ -501,23 +698,71 @@ func GetGitObjectMetadata(hash string) (GitObjectMetadata, error) {
 	return meta, nil
 }
 
+// readRawObject loads the decompressed "<type> <size>\x00<content>" buffer
+// for hash out of the configured ObjectStore, falling back to any packfile
+// the store exposes when no loose object is found.
+func readRawObject(hash string, opts ...Option) (ObjectType, []byte, error) {
+	resolved := resolveOptions(opts)
+	store := resolved.store
+
+	if r, err := store.Get(hash); err == nil {
+		defer r.Close()
+
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return "", nil, err
+		}
+		defer zr.Close()
+
+		decompressed, err := io.ReadAll(zr)
+		if err != nil {
+			return "", nil, err
+		}
+
+		h, _, ok := bytes.Cut(decompressed, []byte("\x00"))
+		if !ok {
+			return "", nil, ErrInvalidGitObject
+		}
+
+		parts := bytes.Split(h, []byte(" "))
+		if len(parts) != 2 {
+			return "", nil, ErrInvalidGitObject
+		}
+
+		return ObjectType(parts[0]), decompressed, nil
+	}
+
+	packType, content, err := findPackedObject(store, hash, resolved.format.Size())
+	if err != nil {
+		return "", nil, err
+	}
+
+	header := ObjectType(packType.String())
+	decompressed := append([]byte(fmt.Sprintf("%s %d\x00", header, len(content))), content...)
+
+	return header, decompressed, nil
+}
+
 // NewGitObjectFromHash takes a sha1 hex string and returns (GitObject, error)
 // on success. Othrwise, it returns (nil, error).
 //
 // Also, this function does not create any subsequent objects for any of the
 // entries in nested GitObjects like TreeObject(s), etc.
-func NewGitObjectFromHash(hash string) (GitObject, error) {
-	meta, err := GetGitObjectMetadata(hash)
+func NewGitObjectFromHash(hash string, opts ...Option) (GitObject, error) {
+	meta, err := GetGitObjectMetadata(hash, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	switch meta.Header {
 	case Blob:
-		return NewBlobObjectFromHash(hash)
+		return NewBlobObjectFromHash(hash, opts...)
 
 	case Tree:
-		return NewTreeObjectFromHash(hash)
+		return NewTreeObjectFromHash(hash, opts...)
+
+	case Commit:
+		return NewCommitObjectFromHash(hash, opts...)
 
 	default:
 		return nil, ErrInvalidGitObject
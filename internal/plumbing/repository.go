@@ -0,0 +1,100 @@
+package plumbing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repository represents a local git repository rooted at gitDir, carrying
+// the object hash algorithm every object it reads or writes is computed
+// with. A single repository can't mix SHA-1 and SHA-256 objects, mirroring
+// real git's extensions.objectFormat.
+type Repository struct {
+	gitDir string
+	format ObjectFormat
+	store  ObjectStore
+}
+
+// OpenRepository opens the repository rooted at gitDir (e.g. "./.git"),
+// reading its hash algorithm from extensions.objectFormat in gitDir/config.
+// A repository with no such extension set defaults to SHA1, matching every
+// repository created before SHA-256 support existed.
+func OpenRepository(gitDir string) (*Repository, error) {
+	format, err := readObjectFormat(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{
+		gitDir: gitDir,
+		format: format,
+		store:  NewLooseObjectStore(gitDir),
+	}, nil
+}
+
+// Format returns the repository's active hash algorithm.
+func (r *Repository) Format() ObjectFormat { return r.format }
+
+// Options returns the Option set plumbing constructors should be called
+// with to operate against this repository's store and hash algorithm.
+func (r *Repository) Options() []Option {
+	return []Option{WithStore(r.store), WithObjectFormat(r.format)}
+}
+
+// WriteRepositoryConfig writes a minimal .git/config for a freshly
+// initialized repository, recording format in extensions.objectFormat
+// whenever it isn't the SHA1 default.
+func WriteRepositoryConfig(gitDir string, format ObjectFormat) error {
+	var config strings.Builder
+	fmt.Fprintln(&config, "[core]")
+	if format == SHA256 {
+		fmt.Fprintln(&config, "\trepositoryformatversion = 1")
+	} else {
+		fmt.Fprintln(&config, "\trepositoryformatversion = 0")
+	}
+	fmt.Fprintln(&config, "\tfilemode = true")
+	fmt.Fprintln(&config, "\tbare = false")
+
+	if format == SHA256 {
+		fmt.Fprintln(&config, "[extensions]")
+		fmt.Fprintln(&config, "\tobjectFormat = sha256")
+	}
+
+	return os.WriteFile(filepath.Join(gitDir, "config"), []byte(config.String()), 0644)
+}
+
+// readObjectFormat reads extensions.objectFormat out of gitDir/config. A
+// missing config file, or one with no such extension set, means SHA1.
+func readObjectFormat(gitDir string) (ObjectFormat, error) {
+	data, err := os.ReadFile(filepath.Join(gitDir, "config"))
+	if errors.Is(err, os.ErrNotExist) {
+		return SHA1, nil
+	}
+	if err != nil {
+		return SHA1, err
+	}
+
+	var section string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "extensions" || strings.TrimSpace(strings.ToLower(key)) != "objectformat" {
+			continue
+		}
+
+		if strings.TrimSpace(value) == "sha256" {
+			return SHA256, nil
+		}
+	}
+
+	return SHA1, nil
+}
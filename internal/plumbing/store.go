@@ -0,0 +1,244 @@
+package plumbing
+
+import (
+	"bytes"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+
+	"github.com/codecrafters-io/git-starter-go/internal/packfile"
+)
+
+// ObjectStore abstracts where git objects are read from and written to, so
+// that plumbing operations don't have to hard-code the ./.git/objects loose
+// file layout. Get/Put deal in the same zlib-compressed
+// "<type> <size>\x00<content>" framing the loose format uses on disk.
+type ObjectStore interface {
+	Get(hash string) (io.ReadCloser, error)
+	Put(hash string, objType ObjectType, data []byte) error
+	Has(hash string) bool
+	IterHashes() iter.Seq[string]
+}
+
+// packFallback is implemented by stores that also know where to look for
+// packed objects once a direct Get misses. Only LooseObjectStore implements
+// it today.
+type packFallback interface {
+	packDir() string
+}
+
+// Option configures the ObjectStore a constructor uses. The zero value of
+// every constructor's options defaults to the loose store rooted at
+// ./.git, matching the CLI's historical behavior.
+type Option func(*options)
+
+type options struct {
+	store  ObjectStore
+	format ObjectFormat
+}
+
+// WithStore overrides the ObjectStore a constructor reads from and writes
+// to, enabling bare repos, alternate object directories, and non-filesystem
+// backends like an in-memory or remote-backed cache.
+func WithStore(store ObjectStore) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithObjectFormat overrides the hash algorithm a constructor computes and
+// parses object shas with. Defaults to SHA1, matching every repository
+// created before SHA-256 support existed.
+func WithObjectFormat(format ObjectFormat) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+func resolveOptions(opts []Option) *options {
+	o := &options{store: defaultStore, format: SHA1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+var defaultStore ObjectStore = NewLooseObjectStore("./.git")
+
+// LooseObjectStore is the traditional "./.git/objects/xx/yyyy..." layout,
+// rooted at a configurable git directory instead of always assuming the
+// current working directory's ./.git.
+type LooseObjectStore struct {
+	root string
+}
+
+// NewLooseObjectStore returns a LooseObjectStore rooted at gitDir (e.g.
+// "./.git", or the top-level directory of a bare repo).
+func NewLooseObjectStore(gitDir string) *LooseObjectStore {
+	return &LooseObjectStore{root: gitDir}
+}
+
+func (s *LooseObjectStore) objectPath(hash string) string {
+	return filepath.Join(s.root, "objects", hash[:2], hash[2:])
+}
+
+func (s *LooseObjectStore) packDir() string {
+	return filepath.Join(s.root, "objects", "pack")
+}
+
+func (s *LooseObjectStore) Get(hash string) (io.ReadCloser, error) {
+	return os.Open(s.objectPath(hash))
+}
+
+func (s *LooseObjectStore) Put(hash string, objType ObjectType, data []byte) error {
+	dir := filepath.Join(s.root, "objects", hash[:2])
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, hash[2:]), data, 0444)
+}
+
+func (s *LooseObjectStore) Has(hash string) bool {
+	_, err := os.Stat(s.objectPath(hash))
+	return err == nil
+}
+
+func (s *LooseObjectStore) IterHashes() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		fanoutDirs, err := os.ReadDir(filepath.Join(s.root, "objects"))
+		if err != nil {
+			return
+		}
+
+		for _, dir := range fanoutDirs {
+			if !dir.IsDir() || len(dir.Name()) != 2 {
+				continue
+			}
+
+			files, err := os.ReadDir(filepath.Join(s.root, "objects", dir.Name()))
+			if err != nil {
+				continue
+			}
+
+			for _, file := range files {
+				if !yield(dir.Name() + file.Name()) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// MemoryObjectStore keeps every object in memory, useful for tests that
+// don't want to touch the filesystem.
+type MemoryObjectStore struct {
+	objects map[string][]byte
+}
+
+// NewMemoryObjectStore returns an empty MemoryObjectStore.
+func NewMemoryObjectStore() *MemoryObjectStore {
+	return &MemoryObjectStore{objects: make(map[string][]byte)}
+}
+
+func (s *MemoryObjectStore) Get(hash string) (io.ReadCloser, error) {
+	data, ok := s.objects[hash]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *MemoryObjectStore) Put(hash string, objType ObjectType, data []byte) error {
+	s.objects[hash] = data
+	return nil
+}
+
+func (s *MemoryObjectStore) Has(hash string) bool {
+	_, ok := s.objects[hash]
+	return ok
+}
+
+func (s *MemoryObjectStore) IterHashes() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for hash := range s.objects {
+			if !yield(hash) {
+				return
+			}
+		}
+	}
+}
+
+// CASStore is a content-addressable directory store parameterized by root
+// path and fanout depth: a hash splits into root/hash[:fanout]/hash[fanout:]
+// the same way the loose store splits on the first two hex characters, but
+// with a configurable split point so it can be pointed at, say, an S3-backed
+// mount or a cache with a different directory-entry budget.
+type CASStore struct {
+	root   string
+	fanout int
+}
+
+// NewCASStore returns a CASStore rooted at root, splitting hashes after the
+// first fanout hex characters.
+func NewCASStore(root string, fanout int) *CASStore {
+	return &CASStore{root: root, fanout: fanout}
+}
+
+func (s *CASStore) objectPath(hash string) string {
+	return filepath.Join(s.root, hash[:s.fanout], hash[s.fanout:])
+}
+
+func (s *CASStore) Get(hash string) (io.ReadCloser, error) {
+	return os.Open(s.objectPath(hash))
+}
+
+func (s *CASStore) Put(hash string, objType ObjectType, data []byte) error {
+	dir := filepath.Join(s.root, hash[:s.fanout])
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, hash[s.fanout:]), data, 0444)
+}
+
+func (s *CASStore) Has(hash string) bool {
+	_, err := os.Stat(s.objectPath(hash))
+	return err == nil
+}
+
+func (s *CASStore) IterHashes() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		fanoutDirs, err := os.ReadDir(s.root)
+		if err != nil {
+			return
+		}
+
+		for _, dir := range fanoutDirs {
+			if !dir.IsDir() || len(dir.Name()) != s.fanout {
+				continue
+			}
+
+			files, err := os.ReadDir(filepath.Join(s.root, dir.Name()))
+			if err != nil {
+				continue
+			}
+
+			for _, file := range files {
+				if !yield(dir.Name() + file.Name()) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// findPackedObject looks up hash across every packfile the store knows
+// about, if it supports pack fallback at all. hashSize is the repository's
+// active object id width in bytes (20 under sha1, 32 under sha256).
+func findPackedObject(store ObjectStore, hash string, hashSize int) (packfile.ObjectType, []byte, error) {
+	pf, ok := store.(packFallback)
+	if !ok {
+		return 0, nil, packfile.ErrObjectNotFound
+	}
+	return packfile.FindObject(pf.packDir(), hash, hashSize)
+}